@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/boolptr"
+	"github.com/vmware-tanzu/velero/pkg/client"
+	velerov1client "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
+)
+
+const (
+	// pollInterval is how often we poll the Velero API server for the status
+	// of a Backup or Restore while waiting for it to complete.
+	pollInterval = 5 * time.Second
+	// pollTimeout is how long we wait for a Backup or Restore to complete
+	// before giving up.
+	pollTimeout = 15 * time.Minute
+)
+
+// getVeleroClient returns a typed client for the velero.io API group. It is
+// used to create and poll Backup/Restore/BackupStorageLocation CRs directly,
+// instead of shelling out to the velero CLI and scraping its stdout.
+func getVeleroClient() (velerov1client.VeleroV1Interface, error) {
+	config, err := client.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	f := client.NewFactory("e2e", config)
+	clientset, err := f.Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get Velero clientset")
+	}
+	return clientset.VeleroV1(), nil
+}
+
+// isBackupDone returns true once a backup has left the New/InProgress phases.
+func isBackupDone(phase velerov1api.BackupPhase) bool {
+	return phase != "" && phase != velerov1api.BackupPhaseNew && phase != velerov1api.BackupPhaseInProgress
+}
+
+// isRestoreDone returns true once a restore has left the New/InProgress phases.
+func isRestoreDone(phase velerov1api.RestorePhase) bool {
+	return phase != "" && phase != velerov1api.RestorePhaseNew && phase != velerov1api.RestorePhaseInProgress
+}
+
+// CheckBackupPhase polls the Backup CR until it reaches a terminal phase and
+// compares it against expectedPhase.
+func CheckBackupPhase(ctx context.Context, veleroNamespace string, backupName string,
+	expectedPhase velerov1api.BackupPhase) error {
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
+	}
+
+	var backup *velerov1api.Backup
+	err = wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(ctx context.Context) (bool, error) {
+		backup, err = veleroClient.Backups(veleroNamespace).Get(ctx, backupName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return isBackupDone(backup.Status.Phase), nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to wait for backup %s to complete", backupName)
+	}
+	if backup.Status.Phase != expectedPhase {
+		return errors.Errorf("Unexpected backup phase got %s, expecting %s", backup.Status.Phase, expectedPhase)
+	}
+	return nil
+}
+
+// CheckRestorePhase polls the Restore CR until it reaches a terminal phase and
+// compares it against expectedPhase.
+func CheckRestorePhase(ctx context.Context, veleroNamespace string, restoreName string,
+	expectedPhase velerov1api.RestorePhase) error {
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
+	}
+
+	var restore *velerov1api.Restore
+	err = wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(ctx context.Context) (bool, error) {
+		restore, err = veleroClient.Restores(veleroNamespace).Get(ctx, restoreName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return isRestoreDone(restore.Status.Phase), nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to wait for restore %s to complete", restoreName)
+	}
+	if restore.Status.Phase != expectedPhase {
+		return errors.Errorf("Unexpected restore phase got %s, expecting %s", restore.Status.Phase, expectedPhase)
+	}
+	return nil
+}
+
+// VeleroBackupNamespace creates a Backup CR for namespace and waits for it to
+// complete.
+func VeleroBackupNamespace(ctx context.Context, veleroNamespace string, backupName string, namespace string, backupLocation string) error {
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
+	}
+
+	backup := &velerov1api.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: veleroNamespace,
+			Name:      backupName,
+		},
+		Spec: velerov1api.BackupSpec{
+			IncludedNamespaces:     []string{namespace},
+			DefaultVolumesToRestic: boolptr.True(),
+			StorageLocation:        backupLocation,
+		},
+	}
+
+	fmt.Printf("creating backup %s for namespace %s\n", backupName, namespace)
+	if _, err := veleroClient.Backups(veleroNamespace).Create(ctx, backup, metav1.CreateOptions{}); err != nil {
+		return errors.Wrap(err, "Failed to create backup")
+	}
+
+	return CheckBackupPhase(ctx, veleroNamespace, backupName, velerov1api.BackupPhaseCompleted)
+}
+
+// VeleroRestore creates a Restore CR from backupName and waits for it to
+// complete.
+func VeleroRestore(ctx context.Context, veleroNamespace string, restoreName string, backupName string) error {
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
+	}
+
+	restore := &velerov1api.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: veleroNamespace,
+			Name:      restoreName,
+		},
+		Spec: velerov1api.RestoreSpec{
+			BackupName: backupName,
+		},
+	}
+
+	fmt.Printf("creating restore %s from backup %s\n", restoreName, backupName)
+	if _, err := veleroClient.Restores(veleroNamespace).Create(ctx, restore, metav1.CreateOptions{}); err != nil {
+		return errors.Wrap(err, "Failed to create restore")
+	}
+
+	return CheckRestorePhase(ctx, veleroNamespace, restoreName, velerov1api.RestorePhaseCompleted)
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/boolptr"
+	"github.com/vmware-tanzu/velero/pkg/client"
+)
+
+// BackupRestoreCase describes one backup/restore scenario to exercise in a
+// Ginkgo suite: hooks to deploy and verify an application, and the phases
+// the backup and restore are expected to reach. New E2E coverage should
+// usually be a struct literal appended to a table (see suites.Cases) rather
+// than a bespoke Ginkgo block.
+type BackupRestoreCase struct {
+	// Name identifies the case in test output.
+	Name string
+	// ApplicationNamespace is the namespace the application under test is
+	// deployed into, backed up, deleted, and restored into.
+	ApplicationNamespace string
+	// DeployFn deploys the application under test into ApplicationNamespace.
+	DeployFn func(ctx context.Context, namespace string) error
+	// VerifyFn runs after the restore completes and should assert that the
+	// application in ApplicationNamespace looks the way DeployFn left it.
+	VerifyFn func(ctx context.Context, namespace string) error
+	// BackupSpecOverrides, if set, is applied on top of the
+	// IncludedNamespaces/DefaultVolumesToRestic defaults
+	// RunBackupRestoreCase sets on the Backup spec.
+	BackupSpecOverrides func(*velerov1api.BackupSpec)
+	// RestoreSpecOverrides, if set, is applied on top of the BackupName
+	// default RunBackupRestoreCase sets on the Restore spec.
+	RestoreSpecOverrides func(*velerov1api.RestoreSpec)
+	// ExpectedBackupPhase is the phase the backup must reach for the case to
+	// pass.
+	ExpectedBackupPhase velerov1api.BackupPhase
+	// ExpectedRestorePhase is the phase the restore must reach for the case
+	// to pass.
+	ExpectedRestorePhase velerov1api.RestorePhase
+}
+
+// RunBackupRestoreCase deploys c's application, backs up its namespace,
+// deletes the namespace, restores it, and runs c.VerifyFn against the
+// result.
+func RunBackupRestoreCase(ctx context.Context, veleroNamespace string, c BackupRestoreCase) error {
+	if err := c.DeployFn(ctx, c.ApplicationNamespace); err != nil {
+		return errors.Wrapf(err, "Failed to deploy application for case %q", c.Name)
+	}
+
+	backupName := fmt.Sprintf("%s-backup", c.ApplicationNamespace)
+	if err := createAndWaitForBackup(ctx, veleroNamespace, backupName, c); err != nil {
+		return err
+	}
+
+	kubeClient, err := GetKubeClient()
+	if err != nil {
+		return err
+	}
+	if err := kubeClient.CoreV1().Namespaces().Delete(ctx, c.ApplicationNamespace, metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed to delete namespace %s before restore", c.ApplicationNamespace)
+	}
+	if err := waitForNamespaceDeleted(ctx, kubeClient, c.ApplicationNamespace); err != nil {
+		return err
+	}
+
+	restoreName := fmt.Sprintf("%s-restore", c.ApplicationNamespace)
+	if err := createAndWaitForRestore(ctx, veleroNamespace, restoreName, backupName, c); err != nil {
+		return err
+	}
+
+	if err := c.VerifyFn(ctx, c.ApplicationNamespace); err != nil {
+		return errors.Wrapf(err, "Verification failed for case %q", c.Name)
+	}
+	return nil
+}
+
+// GetKubeClient returns a client-go clientset built from the ambient
+// kubeconfig, the same way the rest of this package talks to the cluster.
+func GetKubeClient() (kubernetes.Interface, error) {
+	config, err := client.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.NewFactory("e2e", config).KubeClient()
+}
+
+func waitForNamespaceDeleted(ctx context.Context, kubeClient kubernetes.Interface, namespace string) error {
+	err := wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := kubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	return errors.Wrapf(err, "Failed waiting for namespace %s to be deleted", namespace)
+}
+
+func createAndWaitForBackup(ctx context.Context, veleroNamespace, backupName string, c BackupRestoreCase) error {
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
+	}
+
+	spec := velerov1api.BackupSpec{
+		IncludedNamespaces:     []string{c.ApplicationNamespace},
+		DefaultVolumesToRestic: boolptr.True(),
+	}
+	if c.BackupSpecOverrides != nil {
+		c.BackupSpecOverrides(&spec)
+	}
+
+	backup := &velerov1api.Backup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: veleroNamespace, Name: backupName},
+		Spec:       spec,
+	}
+	if _, err := veleroClient.Backups(veleroNamespace).Create(ctx, backup, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed to create backup for case %q", c.Name)
+	}
+	return CheckBackupPhase(ctx, veleroNamespace, backupName, c.ExpectedBackupPhase)
+}
+
+func createAndWaitForRestore(ctx context.Context, veleroNamespace, restoreName, backupName string, c BackupRestoreCase) error {
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
+	}
+
+	spec := velerov1api.RestoreSpec{BackupName: backupName}
+	if c.RestoreSpecOverrides != nil {
+		c.RestoreSpecOverrides(&spec)
+	}
+
+	restore := &velerov1api.Restore{
+		ObjectMeta: metav1.ObjectMeta{Namespace: veleroNamespace, Name: restoreName},
+		Spec:       spec,
+	}
+	if _, err := veleroClient.Restores(veleroNamespace).Create(ctx, restore, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed to create restore for case %q", c.Name)
+	}
+	return CheckRestorePhase(ctx, veleroNamespace, restoreName, c.ExpectedRestorePhase)
+}
@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"testing"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+func TestIsBackupDone(t *testing.T) {
+	tests := []struct {
+		phase velerov1api.BackupPhase
+		done  bool
+	}{
+		{phase: "", done: false},
+		{phase: velerov1api.BackupPhaseNew, done: false},
+		{phase: velerov1api.BackupPhaseInProgress, done: false},
+		{phase: velerov1api.BackupPhaseCompleted, done: true},
+		{phase: velerov1api.BackupPhaseFailed, done: true},
+	}
+
+	for _, tc := range tests {
+		if got := isBackupDone(tc.phase); got != tc.done {
+			t.Errorf("isBackupDone(%q) = %v, want %v", tc.phase, got, tc.done)
+		}
+	}
+}
+
+func TestIsRestoreDone(t *testing.T) {
+	tests := []struct {
+		phase velerov1api.RestorePhase
+		done  bool
+	}{
+		{phase: "", done: false},
+		{phase: velerov1api.RestorePhaseNew, done: false},
+		{phase: velerov1api.RestorePhaseInProgress, done: false},
+		{phase: velerov1api.RestorePhaseCompleted, done: true},
+		{phase: velerov1api.RestorePhaseFailed, done: true},
+	}
+
+	for _, tc := range tests {
+		if got := isRestoreDone(tc.phase); got != tc.done {
+			t.Errorf("isRestoreDone(%q) = %v, want %v", tc.phase, got, tc.done)
+		}
+	}
+}
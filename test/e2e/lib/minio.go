@@ -0,0 +1,263 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	cliinstall "github.com/vmware-tanzu/velero/pkg/cmd/cli/install"
+)
+
+const (
+	minioDeploymentName = "minio"
+	// minioImage is pinned to a release that still honors the legacy
+	// MINIO_ACCESS_KEY/MINIO_SECRET_KEY env vars createMinIOBucket relies on;
+	// later releases require MINIO_ROOT_USER/MINIO_ROOT_PASSWORD instead.
+	minioImage     = "minio/minio:RELEASE.2021-06-17T00-10-46Z"
+	minioAccessKey = "minio"
+	minioSecretKey = "minio123"
+	minioBucket    = "velero-e2e"
+)
+
+// SetupMinIO deploys an in-cluster MinIO instance into namespace, waits for
+// it to become ready, creates the target bucket, and returns InstallOptions
+// pre-populated to point Velero's aws plugin at it. This lets E2E suites
+// against kind clusters run with zero external cloud dependency.
+func SetupMinIO(ctx context.Context, kubeClient kubernetes.Interface, namespace string) (*cliinstall.InstallOptions, error) {
+	if err := createMinIOResources(ctx, kubeClient, namespace); err != nil {
+		return nil, errors.Wrap(err, "Failed to deploy MinIO")
+	}
+
+	if err := waitForMinIODeployment(ctx, kubeClient, namespace); err != nil {
+		return nil, errors.Wrap(err, "Failed waiting for MinIO to become ready")
+	}
+
+	// clusterURL is what the Velero pod, itself running inside the cluster,
+	// will use to reach MinIO via the BSL config below. The test driver
+	// process that bootstraps the bucket runs outside the cluster and can't
+	// resolve a Service's .svc DNS name, so it goes through the Service's
+	// NodePort instead.
+	clusterURL := fmt.Sprintf("http://%s.%s.svc:9000", minioDeploymentName, namespace)
+
+	bootstrapURL, err := minioNodePortURL(ctx, kubeClient, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to determine MinIO NodePort address")
+	}
+	if err := createMinIOBucket(bootstrapURL, minioBucket); err != nil {
+		return nil, errors.Wrap(err, "Failed to create MinIO bucket")
+	}
+
+	credentialsFile, err := writeMinIOCredentialsFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to write MinIO credentials file")
+	}
+
+	plugins, err := NewProviderPluginRegistry().Get("kind")
+	if err != nil {
+		return nil, err
+	}
+	bslConfig := fmt.Sprintf("%s,s3Url=%s,publicUrl=%s", plugins.DefaultBackupStorageConfig(), clusterURL, clusterURL)
+	return GetProviderVeleroInstallOptions(
+		"aws",
+		credentialsFile,
+		minioBucket,
+		"",
+		bslConfig,
+		"",
+		plugins.PluginImages(),
+		"",
+	)
+}
+
+// createMinIOResources creates the MinIO Deployment, Service, and backing PVC
+// in namespace.
+func createMinIOResources(ctx context.Context, kubeClient kubernetes.Interface, namespace string) error {
+	pvc := &corev1api.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: minioDeploymentName},
+		Spec: corev1api.PersistentVolumeClaimSpec{
+			AccessModes: []corev1api.PersistentVolumeAccessMode{corev1api.ReadWriteOnce},
+			Resources: corev1api.ResourceRequirements{
+				Requests: corev1api.ResourceList{
+					corev1api.ResourceStorage: resource.MustParse("1Gi"),
+				},
+			},
+		},
+	}
+	if _, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "Failed to create MinIO PVC")
+	}
+
+	labels := map[string]string{"component": minioDeploymentName}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: minioDeploymentName},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1api.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1api.PodSpec{
+					Containers: []corev1api.Container{
+						{
+							Name:    minioDeploymentName,
+							Image:   minioImage,
+							Command: []string{"minio", "server", "/storage"},
+							Env: []corev1api.EnvVar{
+								{Name: "MINIO_ACCESS_KEY", Value: minioAccessKey},
+								{Name: "MINIO_SECRET_KEY", Value: minioSecretKey},
+							},
+							Ports: []corev1api.ContainerPort{{ContainerPort: 9000}},
+							VolumeMounts: []corev1api.VolumeMount{
+								{Name: "storage", MountPath: "/storage"},
+							},
+						},
+					},
+					Volumes: []corev1api.Volume{
+						{
+							Name: "storage",
+							VolumeSource: corev1api.VolumeSource{
+								PersistentVolumeClaim: &corev1api.PersistentVolumeClaimVolumeSource{ClaimName: minioDeploymentName},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := kubeClient.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "Failed to create MinIO deployment")
+	}
+
+	service := &corev1api.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: minioDeploymentName},
+		Spec: corev1api.ServiceSpec{
+			// NodePort, rather than the default ClusterIP, so the test driver
+			// process running outside the cluster can reach MinIO to
+			// bootstrap the bucket; see minioNodePortURL.
+			Type:     corev1api.ServiceTypeNodePort,
+			Selector: labels,
+			Ports: []corev1api.ServicePort{
+				{Port: 9000, TargetPort: intstr.FromInt(9000)},
+			},
+		},
+	}
+	if _, err := kubeClient.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "Failed to create MinIO service")
+	}
+
+	return nil
+}
+
+// minioNodePortURL returns the address the E2E test driver, which runs
+// outside the cluster, can use to reach the MinIO Service created by
+// createMinIOResources: a cluster node's IP and the Service's assigned
+// NodePort.
+func minioNodePortURL(ctx context.Context, kubeClient kubernetes.Interface, namespace string) (string, error) {
+	svc, err := kubeClient.CoreV1().Services(namespace).Get(ctx, minioDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to get MinIO service")
+	}
+	if len(svc.Spec.Ports) == 0 || svc.Spec.Ports[0].NodePort == 0 {
+		return "", errors.New("MinIO service has no NodePort assigned")
+	}
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to list cluster nodes")
+	}
+	if len(nodes.Items) == 0 {
+		return "", errors.New("No cluster nodes found")
+	}
+
+	nodeIP, err := nodeAddress(nodes.Items[0])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("http://%s:%d", nodeIP, svc.Spec.Ports[0].NodePort), nil
+}
+
+// nodeAddress returns node's internal IP, falling back to its external IP if
+// it has no internal one.
+func nodeAddress(node corev1api.Node) (string, error) {
+	var externalIP string
+	for _, addr := range node.Status.Addresses {
+		switch addr.Type {
+		case corev1api.NodeInternalIP:
+			return addr.Address, nil
+		case corev1api.NodeExternalIP:
+			externalIP = addr.Address
+		}
+	}
+	if externalIP != "" {
+		return externalIP, nil
+	}
+	return "", errors.Errorf("Node %s has no usable IP address", node.Name)
+}
+
+// waitForMinIODeployment polls the MinIO Deployment until all of its replicas
+// are available.
+func waitForMinIODeployment(ctx context.Context, kubeClient kubernetes.Interface, namespace string) error {
+	return wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := kubeClient.AppsV1().Deployments(namespace).Get(ctx, minioDeploymentName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return deployment.Status.AvailableReplicas > 0, nil
+	})
+}
+
+// createMinIOBucket creates bucket on the MinIO instance reachable at s3URL,
+// tolerating a bucket that already exists.
+func createMinIOBucket(s3URL, bucket string) error {
+	minioClient, err := minio.New(trimScheme(s3URL), &minio.Options{
+		Creds: credentials.NewStaticV4(minioAccessKey, minioSecretKey, ""),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to create MinIO client")
+	}
+
+	err = minioClient.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{})
+	if err != nil {
+		exists, existsErr := minioClient.BucketExists(context.Background(), bucket)
+		if existsErr == nil && exists {
+			return nil
+		}
+		return errors.Wrapf(err, "Failed to create bucket %s", bucket)
+	}
+	return nil
+}
+
+// trimScheme strips the http:// prefix minio-go doesn't want in its endpoint.
+func trimScheme(url string) string {
+	const prefix = "http://"
+	if len(url) > len(prefix) && url[:len(prefix)] == prefix {
+		return url[len(prefix):]
+	}
+	return url
+}
+
+// writeMinIOCredentialsFile writes an AWS-style credentials file for the
+// MinIO access/secret key pair and returns its path.
+func writeMinIOCredentialsFile() (string, error) {
+	f, err := os.CreateTemp("", "velero-e2e-minio-credentials")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	contents := fmt.Sprintf("[default]\naws_access_key_id=%s\naws_secret_access_key=%s\n", minioAccessKey, minioSecretKey)
+	if _, err := f.WriteString(contents); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,20 @@
+package lib
+
+import "testing"
+
+func TestTrimScheme(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "http://minio.velero.svc:9000", want: "minio.velero.svc:9000"},
+		{url: "minio.velero.svc:9000", want: "minio.velero.svc:9000"},
+		{url: "https://minio.velero.svc:9000", want: "https://minio.velero.svc:9000"},
+	}
+
+	for _, tc := range tests {
+		if got := trimScheme(tc.url); got != tc.want {
+			t.Errorf("trimScheme(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
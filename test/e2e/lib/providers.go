@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProviderPlugins describes everything VeleroInstall needs to know about a
+// cloud provider: which velero plugin image(s) to install, and the default
+// BSL/VSL config to use when the caller doesn't override it.
+type ProviderPlugins interface {
+	// Name returns the provider name as accepted by `velero install --provider`.
+	Name() string
+	// PluginImages returns the velero plugin image(s) to install for this provider.
+	PluginImages() []string
+	// DefaultBackupStorageConfig returns the default backup-location config
+	// for this provider, as a `key=value,...` string.
+	DefaultBackupStorageConfig() string
+	// DefaultVolumeSnapshotConfig returns the default snapshot-location
+	// config for this provider, as a `key=value,...` string.
+	DefaultVolumeSnapshotConfig() string
+}
+
+// ProviderPluginRegistry looks up the ProviderPlugins for a provider name.
+// Adding support for a new provider means registering an implementation here
+// rather than editing a shared switch statement.
+type ProviderPluginRegistry interface {
+	Get(providerName string) (ProviderPlugins, error)
+	Register(plugins ProviderPlugins)
+}
+
+type providerPluginRegistry struct {
+	providers map[string]ProviderPlugins
+}
+
+// NewProviderPluginRegistry returns a ProviderPluginRegistry pre-populated
+// with the providers this repo ships support for.
+func NewProviderPluginRegistry() ProviderPluginRegistry {
+	r := &providerPluginRegistry{providers: make(map[string]ProviderPlugins)}
+	r.Register(awsPlugins{})
+	r.Register(azurePlugins{})
+	r.Register(gcpPlugins{})
+	r.Register(vspherePlugins{})
+	r.Register(kindPlugins{})
+	return r
+}
+
+func (r *providerPluginRegistry) Register(plugins ProviderPlugins) {
+	r.providers[plugins.Name()] = plugins
+}
+
+func (r *providerPluginRegistry) Get(providerName string) (ProviderPlugins, error) {
+	plugins, ok := r.providers[providerName]
+	if !ok {
+		return nil, errors.Errorf("No plugins registered for provider %q", providerName)
+	}
+	return plugins, nil
+}
+
+// pluginImageOverride lets a CI run pin a different plugin image/version
+// than this repo's default without editing code, e.g.
+// E2E_PLUGIN_IMAGE_AWS=velero/velero-plugin-for-aws:v1.2.0.
+func pluginImageOverride(providerName, defaultImage string) string {
+	envVar := fmt.Sprintf("E2E_PLUGIN_IMAGE_%s", strings.ToUpper(providerName))
+	if image := os.Getenv(envVar); image != "" {
+		return image
+	}
+	return defaultImage
+}
+
+type awsPlugins struct{}
+
+func (awsPlugins) Name() string { return "aws" }
+func (awsPlugins) PluginImages() []string {
+	return []string{pluginImageOverride("aws", "velero/velero-plugin-for-aws:v1.1.0")}
+}
+func (awsPlugins) DefaultBackupStorageConfig() string  { return "" }
+func (awsPlugins) DefaultVolumeSnapshotConfig() string { return "" }
+
+type azurePlugins struct{}
+
+func (azurePlugins) Name() string { return "azure" }
+func (azurePlugins) PluginImages() []string {
+	return []string{pluginImageOverride("azure", "velero/velero-plugin-for-microsoft-azure:v1.1.1")}
+}
+func (azurePlugins) DefaultBackupStorageConfig() string  { return "" }
+func (azurePlugins) DefaultVolumeSnapshotConfig() string { return "" }
+
+type gcpPlugins struct{}
+
+func (gcpPlugins) Name() string { return "gcp" }
+func (gcpPlugins) PluginImages() []string {
+	return []string{pluginImageOverride("gcp", "velero/velero-plugin-for-gcp:v1.1.0")}
+}
+func (gcpPlugins) DefaultBackupStorageConfig() string  { return "" }
+func (gcpPlugins) DefaultVolumeSnapshotConfig() string { return "" }
+
+type vspherePlugins struct{}
+
+func (vspherePlugins) Name() string { return "vsphere" }
+func (vspherePlugins) PluginImages() []string {
+	return []string{
+		pluginImageOverride("aws", "velero/velero-plugin-for-aws:v1.1.0"),
+		pluginImageOverride("vsphere", "velero/velero-plugin-for-vsphere:v1.0.2"),
+	}
+}
+func (vspherePlugins) DefaultBackupStorageConfig() string  { return "" }
+func (vspherePlugins) DefaultVolumeSnapshotConfig() string { return "" }
+
+// kindPlugins backs a kind cluster with an in-cluster MinIO instance, which
+// speaks the S3 API, so it reuses the aws plugin image but defaults its BSL
+// config to the MinIO region/URL set up by SetupMinIO.
+type kindPlugins struct{}
+
+func (kindPlugins) Name() string { return "kind" }
+func (kindPlugins) PluginImages() []string {
+	return []string{pluginImageOverride("aws", "velero/velero-plugin-for-aws:v1.1.0")}
+}
+func (kindPlugins) DefaultBackupStorageConfig() string {
+	return "region=minio,s3ForcePathStyle=true"
+}
+func (kindPlugins) DefaultVolumeSnapshotConfig() string { return "" }
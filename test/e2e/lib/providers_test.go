@@ -0,0 +1,44 @@
+package lib
+
+import "testing"
+
+func TestProviderPluginRegistry(t *testing.T) {
+	registry := NewProviderPluginRegistry()
+
+	for _, name := range []string{"aws", "azure", "gcp", "vsphere", "kind"} {
+		if _, err := registry.Get(name); err != nil {
+			t.Errorf("Get(%q) returned unexpected error: %v", name, err)
+		}
+	}
+
+	if _, err := registry.Get("does-not-exist"); err == nil {
+		t.Error("Get() of an unregistered provider should return an error")
+	}
+}
+
+func TestProviderPluginRegistryRegisterOverrides(t *testing.T) {
+	registry := NewProviderPluginRegistry()
+	registry.Register(awsPlugins{})
+
+	plugins, err := registry.Get("aws")
+	if err != nil {
+		t.Fatalf("Get(\"aws\") returned unexpected error: %v", err)
+	}
+	if plugins.Name() != "aws" {
+		t.Errorf("got provider %q, want %q", plugins.Name(), "aws")
+	}
+}
+
+func TestPluginImageOverride(t *testing.T) {
+	const defaultImage = "velero/velero-plugin-for-aws:v1.1.0"
+
+	if got := pluginImageOverride("aws", defaultImage); got != defaultImage {
+		t.Errorf("with no env var set, got %q, want default %q", got, defaultImage)
+	}
+
+	const overrideImage = "velero/velero-plugin-for-aws:v1.2.0"
+	t.Setenv("E2E_PLUGIN_IMAGE_AWS", overrideImage)
+	if got := pluginImageOverride("aws", defaultImage); got != overrideImage {
+		t.Errorf("with E2E_PLUGIN_IMAGE_AWS set, got %q, want override %q", got, overrideImage)
+	}
+}
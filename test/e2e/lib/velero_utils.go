@@ -1,15 +1,15 @@
-package e2e
+package lib
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 
 	"github.com/pkg/errors"
+	corev1api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
@@ -20,21 +20,9 @@ import (
 	"github.com/vmware-tanzu/velero/pkg/cmd/cli/uninstall"
 	"github.com/vmware-tanzu/velero/pkg/cmd/util/flag"
 	"github.com/vmware-tanzu/velero/pkg/install"
-)
 
-func getProviderPlugins(providerName string) []string {
-	// TODO: make plugin images configurable
-	switch providerName {
-	case "aws":
-		return []string{"velero/velero-plugin-for-aws:v1.1.0"}
-	case "azure":
-		return []string{"velero/velero-plugin-for-microsoft-azure:v1.1.1"}
-	case "vsphere":
-		return []string{"velero/velero-plugin-for-aws:v1.1.0", "velero/velero-plugin-for-vsphere:v1.0.2"}
-	default:
-		return []string{""}
-	}
-}
+	"github.com/vmware-tanzu/velero/test/e2e/utils"
+)
 
 // GetProviderVeleroInstallOptions returns Velero InstallOptions for the provider.
 func GetProviderVeleroInstallOptions(
@@ -121,136 +109,9 @@ func InstallVeleroServer(io *cliinstall.InstallOptions) error {
 	return nil
 }
 
-// CheckBackupPhase uses veleroCLI to inspect the phase of a Velero backup.
-func CheckBackupPhase(ctx context.Context, veleroCLI string, veleroNamespace string, backupName string,
-	expectedPhase velerov1api.BackupPhase) error {
-	checkCMD := exec.CommandContext(ctx, veleroCLI, "--namespace", veleroNamespace, "backup", "get", "-o", "json",
-		backupName)
-
-	fmt.Printf("get backup cmd =%v\n", checkCMD)
-	stdoutPipe, err := checkCMD.StdoutPipe()
-	if err != nil {
-		return err
-	}
-
-	jsonBuf := make([]byte, 16*1024) // If the YAML is bigger than 16K, there's probably something bad happening
-
-	err = checkCMD.Start()
-	if err != nil {
-		return err
-	}
-
-	bytesRead, err := io.ReadFull(stdoutPipe, jsonBuf)
-
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return err
-	}
-	if bytesRead == len(jsonBuf) {
-		return errors.New("yaml returned bigger than max allowed")
-	}
-
-	jsonBuf = jsonBuf[0:bytesRead]
-	err = checkCMD.Wait()
-	if err != nil {
-		return err
-	}
-	backup := velerov1api.Backup{}
-	err = json.Unmarshal(jsonBuf, &backup)
-	if err != nil {
-		return err
-	}
-	if backup.Status.Phase != expectedPhase {
-		return errors.Errorf("Unexpected backup phase got %s, expecting %s", backup.Status.Phase, expectedPhase)
-	}
-	return nil
-}
-
-// CheckRestorePhase uses veleroCLI to inspect the phase of a Velero restore.
-func CheckRestorePhase(ctx context.Context, veleroCLI string, veleroNamespace string, restoreName string,
-	expectedPhase velerov1api.RestorePhase) error {
-	checkCMD := exec.CommandContext(ctx, veleroCLI, "--namespace", veleroNamespace, "restore", "get", "-o", "json",
-		restoreName)
-
-	fmt.Printf("get restore cmd =%v\n", checkCMD)
-	stdoutPipe, err := checkCMD.StdoutPipe()
-	if err != nil {
-		return err
-	}
-
-	jsonBuf := make([]byte, 16*1024) // If the YAML is bigger than 16K, there's probably something bad happening
-
-	err = checkCMD.Start()
-	if err != nil {
-		return err
-	}
-
-	bytesRead, err := io.ReadFull(stdoutPipe, jsonBuf)
-
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return err
-	}
-	if bytesRead == len(jsonBuf) {
-		return errors.New("yaml returned bigger than max allowed")
-	}
-
-	jsonBuf = jsonBuf[0:bytesRead]
-	err = checkCMD.Wait()
-	if err != nil {
-		return err
-	}
-	restore := velerov1api.Restore{}
-	err = json.Unmarshal(jsonBuf, &restore)
-	if err != nil {
-		return err
-	}
-	if restore.Status.Phase != expectedPhase {
-		return errors.Errorf("Unexpected restore phase got %s, expecting %s", restore.Status.Phase, expectedPhase)
-	}
-	return nil
-}
-
-// VeleroBackupNamespace uses the veleroCLI to backup a namespace.
-func VeleroBackupNamespace(ctx context.Context, veleroCLI string, veleroNamespace string, backupName string, namespace string, backupLocation string) error {
-	args := []string{
-		"--namespace", veleroNamespace,
-		"create", "backup", backupName,
-		"--include-namespaces", namespace,
-		"--default-volumes-to-restic",
-		"--wait",
-	}
-
-	if backupLocation != "" {
-		args = append(args, "--storage-location", backupLocation)
-	}
-
-	backupCmd := exec.CommandContext(ctx, veleroCLI, args...)
-	backupCmd.Stdout = os.Stdout
-	backupCmd.Stderr = os.Stderr
-	fmt.Printf("backup cmd =%v\n", backupCmd)
-	err := backupCmd.Run()
-	if err != nil {
-		return err
-	}
-	err = CheckBackupPhase(ctx, veleroCLI, veleroNamespace, backupName, velerov1api.BackupPhaseCompleted)
-
-	return err
-}
-
-// VeleroRestore uses the veleroCLI to restore from a Velero backup.
-func VeleroRestore(ctx context.Context, veleroCLI string, veleroNamespace string, restoreName string, backupName string) error {
-	restoreCmd := exec.CommandContext(ctx, veleroCLI, "--namespace", veleroNamespace, "create", "restore", restoreName,
-		"--from-backup", backupName, "--wait")
-
-	restoreCmd.Stdout = os.Stdout
-	restoreCmd.Stderr = os.Stderr
-	fmt.Printf("restore cmd =%v\n", restoreCmd)
-	err := restoreCmd.Run()
-	if err != nil {
-		return err
-	}
-	return CheckRestorePhase(ctx, veleroCLI, veleroNamespace, restoreName, velerov1api.RestorePhaseCompleted)
-}
-
+// VeleroInstall installs Velero in the target cluster using the
+// ProviderPluginRegistry to resolve plugin images and default BSL/VSL config
+// for objectStoreProvider.
 func VeleroInstall(ctx context.Context, veleroImage string, veleroNamespace string, cloudProvider string, objectStoreProvider string, useVolumeSnapshots bool,
 	cloudCredentialsFile string, bslBucket string, bslPrefix string, bslConfig string, vslConfig string,
 	features string) error {
@@ -265,14 +126,46 @@ func VeleroInstall(ctx context.Context, veleroImage string, veleroNamespace stri
 			return errors.New("No object store provider specified - must be specified when using kind as the cloud provider") // Gotta have an object store provider
 		}
 	}
-	err := EnsureClusterExists(ctx)
+	err := utils.EnsureClusterExists(ctx)
 	if err != nil {
 		return errors.WithMessage(err, "Failed to ensure kubernetes cluster exists")
 	}
-	veleroInstallOptions, err := GetProviderVeleroInstallOptions(objectStoreProvider, cloudCredentialsFile, bslBucket,
-		bslPrefix, bslConfig, vslConfig, getProviderPlugins(objectStoreProvider), features)
-	if err != nil {
-		return errors.WithMessagef(err, "Failed to get Velero InstallOptions for plugin provider %s", objectStoreProvider)
+
+	var veleroInstallOptions *cliinstall.InstallOptions
+	if cloudProvider == "kind" && objectStoreProvider == "aws" && cloudCredentialsFile == "" {
+		// No external object store was supplied: bootstrap one in-cluster so
+		// kind-based runs don't need a cloud dependency.
+		kubeClient, err := GetKubeClient()
+		if err != nil {
+			return errors.WithMessage(err, "Failed to get Kubernetes client")
+		}
+		veleroInstallOptions, err = SetupMinIO(ctx, kubeClient, veleroNamespace)
+		if err != nil {
+			return errors.WithMessage(err, "Failed to set up MinIO")
+		}
+	} else {
+		credentialsFile, err := utils.LoadCredentialsFile(cloudCredentialsFile)
+		if err != nil {
+			return errors.WithMessage(err, "Failed to resolve credentials file")
+		}
+
+		registry := NewProviderPluginRegistry()
+		plugins, err := registry.Get(objectStoreProvider)
+		if err != nil {
+			return errors.WithMessagef(err, "Failed to get plugins for provider %s", objectStoreProvider)
+		}
+		if bslConfig == "" {
+			bslConfig = plugins.DefaultBackupStorageConfig()
+		}
+		if vslConfig == "" {
+			vslConfig = plugins.DefaultVolumeSnapshotConfig()
+		}
+
+		veleroInstallOptions, err = GetProviderVeleroInstallOptions(objectStoreProvider, credentialsFile, bslBucket,
+			bslPrefix, bslConfig, vslConfig, plugins.PluginImages(), features)
+		if err != nil {
+			return errors.WithMessagef(err, "Failed to get Velero InstallOptions for plugin provider %s", objectStoreProvider)
+		}
 	}
 	veleroInstallOptions.UseRestic = !useVolumeSnapshots
 	veleroInstallOptions.Image = veleroImage
@@ -288,6 +181,9 @@ func VeleroUninstall(ctx context.Context, client *kubernetes.Clientset, extensio
 	return uninstall.Uninstall(ctx, client, extensionsClient, veleroNamespace)
 }
 
+// VeleroBackupLogs still shells out to the velero CLI: `describe`/`logs`
+// output is meant for a human to read, not for this package to parse, so
+// there's no native-client equivalent worth building.
 func VeleroBackupLogs(ctx context.Context, veleroCLI string, veleroNamespace string, backupName string) error {
 	describeCmd := exec.CommandContext(ctx, veleroCLI, "--namespace", veleroNamespace, "backup", "describe", backupName)
 	describeCmd.Stdout = os.Stdout
@@ -306,6 +202,7 @@ func VeleroBackupLogs(ctx context.Context, veleroCLI string, veleroNamespace str
 	return nil
 }
 
+// VeleroRestoreLogs still shells out to the velero CLI; see VeleroBackupLogs.
 func VeleroRestoreLogs(ctx context.Context, veleroCLI string, veleroNamespace string, restoreName string) error {
 	describeCmd := exec.CommandContext(ctx, veleroCLI, "--namespace", veleroNamespace, "restore", "describe", restoreName)
 	describeCmd.Stdout = os.Stdout
@@ -324,8 +221,8 @@ func VeleroRestoreLogs(ctx context.Context, veleroCLI string, veleroNamespace st
 	return nil
 }
 
+// VeleroCreateBackupLocation creates a BackupStorageLocation CR directly.
 func VeleroCreateBackupLocation(ctx context.Context,
-	veleroCLI string,
 	veleroNamespace string,
 	name string,
 	objectStoreProvider string,
@@ -335,28 +232,43 @@ func VeleroCreateBackupLocation(ctx context.Context,
 	secretName string,
 	secretKey string,
 ) error {
-	args := []string{
-		"--namespace", veleroNamespace,
-		"create", "backup-location", name,
-		"--provider", objectStoreProvider,
-		"--bucket", bucket,
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return err
 	}
 
-	if prefix != "" {
-		args = append(args, "--prefix", prefix)
+	bslConfig := flag.NewMap()
+	if config != "" {
+		if err := bslConfig.Set(config); err != nil {
+			return errors.Wrapf(err, "Failed to parse BSL config %q", config)
+		}
 	}
 
-	if config != "" {
-		args = append(args, "--config", config)
+	bsl := &velerov1api.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: veleroNamespace,
+			Name:      name,
+		},
+		Spec: velerov1api.BackupStorageLocationSpec{
+			Provider: objectStoreProvider,
+			StorageType: velerov1api.StorageType{
+				ObjectStorage: &velerov1api.ObjectStorageLocation{
+					Bucket: bucket,
+					Prefix: prefix,
+				},
+			},
+			Config: bslConfig.Data(),
+		},
 	}
 
 	if secretName != "" && secretKey != "" {
-		args = append(args, "--credential", fmt.Sprintf("%s=%s", secretName, secretKey))
+		bsl.Spec.Credential = &corev1api.SecretKeySelector{
+			LocalObjectReference: corev1api.LocalObjectReference{Name: secretName},
+			Key:                  secretKey,
+		}
 	}
 
-	bslCreateCmd := exec.CommandContext(ctx, veleroCLI, args...)
-	bslCreateCmd.Stdout = os.Stdout
-	bslCreateCmd.Stderr = os.Stderr
-
-	return bslCreateCmd.Run()
+	fmt.Printf("creating backup-location %s\n", name)
+	_, err = veleroClient.BackupStorageLocations(veleroNamespace).Create(ctx, bsl, metav1.CreateOptions{})
+	return errors.Wrapf(err, "Failed to create backup-location %s", name)
 }
@@ -0,0 +1,232 @@
+package suites
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/test/e2e/lib"
+)
+
+const (
+	// deploymentReadyPollInterval is how often we poll a restored Deployment
+	// while waiting for its pod(s) to become available. A restore CR reaches
+	// Completed as soon as the underlying objects are created, well before a
+	// pod has been scheduled, pulled its image, and gone Ready (or, for
+	// restic-backed cases, before the filesystem restore has even started),
+	// so verification has to poll rather than check once.
+	deploymentReadyPollInterval = 5 * time.Second
+	// deploymentReadyPollTimeout is how long we wait for a restored
+	// Deployment to report an available replica before giving up.
+	deploymentReadyPollTimeout = 5 * time.Minute
+)
+
+// Cases is a small library of ready-made BackupRestoreCases. Add coverage by
+// appending a struct literal here rather than writing a bespoke Ginkgo block.
+var Cases = []lib.BackupRestoreCase{
+	statelessNginxCase(),
+	mysqlWithResticCase(),
+	multiNamespaceCase(),
+}
+
+// statelessNginxCase backs up and restores a plain nginx Deployment with no
+// persistent state.
+func statelessNginxCase() lib.BackupRestoreCase {
+	return lib.BackupRestoreCase{
+		Name:                 "stateless-nginx",
+		ApplicationNamespace: "e2e-stateless-nginx",
+		DeployFn:             deployNginx,
+		VerifyFn:             verifyDeploymentAvailable("nginx"),
+		ExpectedBackupPhase:  velerov1api.BackupPhaseCompleted,
+		ExpectedRestorePhase: velerov1api.RestorePhaseCompleted,
+	}
+}
+
+// mysqlWithResticCase backs up and restores a MySQL Deployment backed by a
+// PVC, exercising restic's filesystem backup/restore path.
+func mysqlWithResticCase() lib.BackupRestoreCase {
+	return lib.BackupRestoreCase{
+		Name:                 "mysql-with-restic",
+		ApplicationNamespace: "e2e-mysql-restic",
+		DeployFn:             deployMySQL,
+		VerifyFn:             verifyDeploymentAvailable("mysql"),
+		ExpectedBackupPhase:  velerov1api.BackupPhaseCompleted,
+		ExpectedRestorePhase: velerov1api.RestorePhaseCompleted,
+	}
+}
+
+// multiNamespaceCase backs up and restores an app whose resources span the
+// primary ApplicationNamespace and a companion namespace, exercising backups
+// with more than one IncludedNamespaces entry.
+func multiNamespaceCase() lib.BackupRestoreCase {
+	const namespace = "e2e-multi-ns-primary"
+	companion := namespace + "-secondary"
+
+	return lib.BackupRestoreCase{
+		Name:                 "multi-namespace-app",
+		ApplicationNamespace: namespace,
+		DeployFn: func(ctx context.Context, ns string) error {
+			if err := deployNginx(ctx, ns); err != nil {
+				return err
+			}
+			return deployNginx(ctx, companion)
+		},
+		VerifyFn: func(ctx context.Context, ns string) error {
+			if err := verifyDeploymentAvailable("nginx")(ctx, ns); err != nil {
+				return err
+			}
+			return verifyDeploymentAvailable("nginx")(ctx, companion)
+		},
+		BackupSpecOverrides: func(spec *velerov1api.BackupSpec) {
+			spec.IncludedNamespaces = []string{namespace, companion}
+		},
+		ExpectedBackupPhase:  velerov1api.BackupPhaseCompleted,
+		ExpectedRestorePhase: velerov1api.RestorePhaseCompleted,
+	}
+}
+
+func deployNginx(ctx context.Context, namespace string) error {
+	kubeClient, err := lib.GetKubeClient()
+	if err != nil {
+		return err
+	}
+	if err := ensureNamespace(ctx, namespace); err != nil {
+		return err
+	}
+
+	labels := map[string]string{"app": "nginx"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nginx"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1api.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1api.PodSpec{
+					Containers: []corev1api.Container{
+						{Name: "nginx", Image: "nginx:latest", Ports: []corev1api.ContainerPort{{ContainerPort: 80}}},
+					},
+				},
+			},
+		},
+	}
+	_, err = kubeClient.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "Failed to create nginx deployment")
+	}
+	return nil
+}
+
+func deployMySQL(ctx context.Context, namespace string) error {
+	kubeClient, err := lib.GetKubeClient()
+	if err != nil {
+		return err
+	}
+	if err := ensureNamespace(ctx, namespace); err != nil {
+		return err
+	}
+
+	pvc := &corev1api.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "mysql-data"},
+		Spec: corev1api.PersistentVolumeClaimSpec{
+			AccessModes: []corev1api.PersistentVolumeAccessMode{corev1api.ReadWriteOnce},
+			Resources: corev1api.ResourceRequirements{
+				Requests: corev1api.ResourceList{corev1api.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	if _, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "Failed to create mysql PVC")
+	}
+
+	labels := map[string]string{"app": "mysql"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "mysql",
+			Annotations: map[string]string{
+				// tells the Velero restic plugin which volume(s) to back up.
+				"backup.velero.io/backup-volumes": "mysql-data",
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1api.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+					Annotations: map[string]string{
+						"backup.velero.io/backup-volumes": "mysql-data",
+					},
+				},
+				Spec: corev1api.PodSpec{
+					Containers: []corev1api.Container{
+						{
+							Name:  "mysql",
+							Image: "mysql:8.0",
+							Env: []corev1api.EnvVar{
+								{Name: "MYSQL_ROOT_PASSWORD", Value: "password"},
+							},
+							VolumeMounts: []corev1api.VolumeMount{
+								{Name: "mysql-data", MountPath: "/var/lib/mysql"},
+							},
+						},
+					},
+					Volumes: []corev1api.Volume{
+						{
+							Name: "mysql-data",
+							VolumeSource: corev1api.VolumeSource{
+								PersistentVolumeClaim: &corev1api.PersistentVolumeClaimVolumeSource{ClaimName: "mysql-data"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, err = kubeClient.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "Failed to create mysql deployment")
+	}
+	return nil
+}
+
+func ensureNamespace(ctx context.Context, namespace string) error {
+	kubeClient, err := lib.GetKubeClient()
+	if err != nil {
+		return err
+	}
+	ns := &corev1api.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	_, err = kubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "Failed to create namespace %s", namespace)
+	}
+	return nil
+}
+
+func verifyDeploymentAvailable(name string) func(ctx context.Context, namespace string) error {
+	return func(ctx context.Context, namespace string) error {
+		kubeClient, err := lib.GetKubeClient()
+		if err != nil {
+			return err
+		}
+
+		err = wait.PollUntilContextTimeout(ctx, deploymentReadyPollInterval, deploymentReadyPollTimeout, true, func(ctx context.Context) (bool, error) {
+			deployment, err := kubeClient.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return deployment.Status.AvailableReplicas > 0, nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Failed waiting for restored deployment %s/%s to become available", namespace, name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,4 @@
+// Package suites holds the Ginkgo E2E suites themselves. Reusable helpers
+// live in test/e2e/lib and test/e2e/utils so suites stay focused on
+// orchestrating a scenario against those building blocks.
+package suites
@@ -0,0 +1,32 @@
+package suites_test
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/vmware-tanzu/velero/test/e2e/lib"
+	"github.com/vmware-tanzu/velero/test/e2e/suites"
+)
+
+var veleroNamespace = flag.String("velero-namespace", "velero", "Namespace Velero is installed into for this E2E run.")
+
+func TestE2E(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Velero E2E Suite")
+}
+
+// This Describe walks suites.Cases so that contributors can add coverage by
+// appending a BackupRestoreCase to that table rather than writing a new
+// Ginkgo block.
+var _ = Describe("Backup and restore", func() {
+	for _, c := range suites.Cases {
+		c := c
+		It(c.Name, func() {
+			Expect(lib.RunBackupRestoreCase(context.Background(), *veleroNamespace, c)).To(Succeed())
+		})
+	}
+})
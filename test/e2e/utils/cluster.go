@@ -0,0 +1,73 @@
+// Package utils holds helpers shared by the e2e suites that don't belong to
+// the Velero-specific lib package: bootstrapping the target cluster and
+// loading provider credentials.
+package utils
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EnsureClusterExists verifies that a target Kubernetes cluster is reachable,
+// creating a local kind cluster named "velero-e2e" if one doesn't already
+// exist. Callers that already have a kubeconfig pointed at a live cluster
+// (cloud-provisioned or otherwise) can skip kind bootstrap entirely by
+// setting KUBECONFIG.
+func EnsureClusterExists(ctx context.Context) error {
+	if os.Getenv("KUBECONFIG") != "" {
+		return nil
+	}
+
+	exists, err := KindClusterExists(ctx, "velero-e2e")
+	if err != nil {
+		return errors.Wrap(err, "Failed to check for kind cluster")
+	}
+	if exists {
+		return nil
+	}
+	return createKindCluster(ctx, "velero-e2e")
+}
+
+// KindClusterExists reports whether a kind cluster with the given name is
+// already running.
+func KindClusterExists(ctx context.Context, name string) (bool, error) {
+	out, err := exec.CommandContext(ctx, "kind", "get", "clusters").Output()
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to list kind clusters")
+	}
+	for _, cluster := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if cluster == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// createKindCluster shells out to `kind create cluster` to bootstrap a new
+// local cluster named name.
+func createKindCluster(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "kind", "create", "cluster", "--name", name)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "Failed to create kind cluster %s", name)
+	}
+	return nil
+}
+
+// LoadCredentialsFile resolves the path to the object store credentials file
+// for an E2E run, falling back to the CREDENTIALS_FILE environment variable
+// when credentialsFile is empty.
+func LoadCredentialsFile(credentialsFile string) (string, error) {
+	if credentialsFile != "" {
+		return credentialsFile, nil
+	}
+	if envFile := os.Getenv("CREDENTIALS_FILE"); envFile != "" {
+		return envFile, nil
+	}
+	return "", errors.New("No credentials were supplied to use for E2E tests")
+}